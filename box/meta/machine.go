@@ -16,18 +16,53 @@ type State struct {
 	Action Action //action of this state when ticks expire
 	Args   interface{}
 
+	// Parent names the state this one nests under, making it a
+	// substate. A substate inherits its parent's Action and Ticks
+	// cadence unless it sets its own, and transitions crossing
+	// branches fire OnExit/OnEnter along the path between them.
+	Parent string
+
+	// OnEnter, if set, fires once when this state becomes active,
+	// including entries caused by moving into one of its substates
+	// from outside its subtree.
+	OnEnter Action
+
+	// OnExit, if set, fires once when this state stops being active,
+	// including exits caused by moving out of its subtree to a state
+	// outside it.
+	OnExit Action
+
+	// StickyTicks keeps this state's tick cadence running across a
+	// re-entry instead of restarting it from zero. Off by default, so
+	// a state normally resumes counting Ticks from scratch whenever it
+	// becomes active again.
+	StickyTicks bool
+
 	tickCnt uint          //ticks already passed after started
 	machine *StateMachine //reference to owner
 }
 
+// Transition describes a guarded move from From to To triggered by
+// an event name registered via RegisterTransition.
+type Transition struct {
+	From   string                      //name of the state the transition departs from
+	To     string                      //name of the state the transition arrives at
+	Guard  func(args interface{}) bool //optional predicate gating the transition, nil means always allowed
+	Action Action                      //optional action run after the guard passes, before moving state
+}
+
 // StateMachine sums all states and related options to make a DFA.
 type StateMachine struct {
 	name   string
 	states map[string]*State // not goroutine-safe, use in read-only mode after initialization
 
-	starting string // name of starting state
-	stopping string // name of stopping state
-	saved    string // save state for later restore
+	starting string   // name of starting state
+	stopping string   // name of stopping state
+	saved    []string // save the full active-state path for later restore
+
+	// transitions maps a departure state name to its registered
+	// events, not goroutine-safe, use in read-only mode after initialization
+	transitions map[string]map[string]*Transition
 
 	//current  string       // active state
 	//mutex    sync.RWMutex // mutex for active state
@@ -44,22 +79,58 @@ type StateMachine struct {
 // with the given name and ticker duration.
 func NewStateMachine(name string, precision time.Duration) *StateMachine {
 	sm := &StateMachine{
-		name:      name,
-		states:    make(map[string]*State),
-		precision: precision,
-		ticker:    time.NewTicker(precision),
-		quit:      make(chan struct{}),
-		stopped:   make(chan struct{}),
-		trace:     false,
+		name:        name,
+		states:      make(map[string]*State),
+		transitions: make(map[string]map[string]*Transition),
+		precision:   precision,
+		ticker:      time.NewTicker(precision),
+		quit:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+		trace:       false,
 	}
 
 	return sm
 }
 
+// effectiveAction returns this state's Action, or the nearest
+// ancestor's Action when this state doesn't define its own.
+func (s *State) effectiveAction() Action {
+	for cur := s; cur != nil; cur = cur.parent() {
+		if cur.Action != nil {
+			return cur.Action
+		}
+	}
+
+	return nil
+}
+
+// effectiveTicks returns this state's Ticks, or the nearest
+// ancestor's Ticks when this state leaves it unset.
+func (s *State) effectiveTicks() uint {
+	for cur := s; cur != nil; cur = cur.parent() {
+		if cur.Ticks != 0 {
+			return cur.Ticks
+		}
+	}
+
+	return 0
+}
+
+// parent returns the State.Parent of s, or nil at the root.
+func (s *State) parent() *State {
+	if s.Parent == "" {
+		return nil
+	}
+
+	return s.machine.states[s.Parent]
+}
+
 func (s *State) trigger() {
 	s.tickCnt++
-	if 0 == s.Ticks || s.tickCnt%s.Ticks == 0 {
-		if s.Action != nil {
+
+	ticks := s.effectiveTicks()
+	if 0 == ticks || s.tickCnt%ticks == 0 {
+		if action := s.effectiveAction(); action != nil {
 			if s.tickCnt%5 == 0 {
 				if s.machine.trace {
 					log.Debugf("state machine [%s] trigger action %s", s.machine.name, s.Name)
@@ -69,7 +140,7 @@ func (s *State) trigger() {
 				//}
 			}
 
-			s.Action(s.Args)
+			action(s.Args)
 
 			if s.machine.stopping != "" && s.Name == s.machine.stopping {
 				close(s.machine.stopped)
@@ -92,9 +163,12 @@ func (sm *StateMachine) EnableStateTrace(on bool) {
 	sm.trace = on
 }
 
-// MoveToState moves the current state to the vien one
+// MoveToState moves the current state to the given one, running
+// OnExit hooks from the old state up to the least-common-ancestor
+// with the new state, then OnEnter hooks from there down to it.
 func (sm *StateMachine) MoveToState(s string) bool {
-	if sm.GetState() == s {
+	from := sm.GetState()
+	if from == s {
 		log.Tracef("state machine [%s] is already in state %s", sm.name, sm.GetState())
 		return true
 	}
@@ -111,6 +185,8 @@ func (sm *StateMachine) MoveToState(s string) bool {
 	//	log.Tracef("state machine [%s] move state from %s to %s", sm.name, sm.current, s)
 	//}
 
+	sm.runTransitionHooks(from, s)
+
 	//sm.mutex.Lock()
 	//defer sm.mutex.Unlock()
 	//sm.current = s
@@ -119,6 +195,111 @@ func (sm *StateMachine) MoveToState(s string) bool {
 	return true
 }
 
+// path returns the chain of state names from the root down to name,
+// root first, nil when name is empty and an empty path when it's unknown.
+func (sm *StateMachine) path(name string) []string {
+	if name == "" {
+		return nil
+	}
+
+	var rev []string
+	for cur := sm.states[name]; cur != nil; cur = cur.parent() {
+		rev = append(rev, cur.Name)
+	}
+
+	path := make([]string, len(rev))
+	for i, n := range rev {
+		path[len(rev)-1-i] = n
+	}
+
+	return path
+}
+
+// runTransitionHooks fires OnExit for every state from "from" up to
+// (but excluding) the least-common-ancestor with "to", then OnEnter
+// for every state below the LCA down to and including "to".
+func (sm *StateMachine) runTransitionHooks(from, to string) {
+	fromPath := sm.path(from)
+	toPath := sm.path(to)
+
+	lca := 0
+	for lca < len(fromPath) && lca < len(toPath) && fromPath[lca] == toPath[lca] {
+		lca++
+	}
+
+	for i := len(fromPath) - 1; i >= lca; i-- {
+		st := sm.states[fromPath[i]]
+		if st != nil && st.OnExit != nil {
+			st.OnExit(st.Args)
+		}
+	}
+
+	for i := lca; i < len(toPath); i++ {
+		st := sm.states[toPath[i]]
+		if st == nil {
+			continue
+		}
+
+		if st.OnEnter != nil {
+			st.OnEnter(st.Args)
+		}
+
+		// a re-entered state restarts its tick cadence unless it
+		// opted out via StickyTicks
+		if !st.StickyTicks {
+			st.tickCnt = 0
+		}
+	}
+}
+
+// RegisterTransition registers a guarded move from Transition.From to
+// Transition.To, fired by Fire(event, ...) while the machine is in
+// Transition.From.
+//
+//	NOTE: This method is not goroutine-safe, call it when initialization only.
+func (sm *StateMachine) RegisterTransition(event string, t *Transition) bool {
+	if t == nil || len(t.From) == 0 || len(t.To) == 0 {
+		log.Errorf("state machine [%s] reg invalid transition for event %s, ignored", sm.name, event)
+		return false
+	}
+
+	if _, ok := sm.transitions[t.From]; !ok {
+		sm.transitions[t.From] = make(map[string]*Transition)
+	}
+
+	sm.transitions[t.From][event] = t
+
+	log.Debugf("state machine [%s] registers transition %s -[%s]-> %s", sm.name, t.From, event, t.To)
+
+	return true
+}
+
+// Fire looks up the transition registered for the current state and
+// the given event, evaluates its guard with args, and moves the
+// machine to Transition.To if the guard passes (or is absent).
+// Rejected or unregistered transitions are logged at trace level
+// and false is returned.
+func (sm *StateMachine) Fire(event string, args interface{}) bool {
+	current := sm.GetState()
+
+	t, ok := sm.transitions[current][event]
+	if !ok {
+		log.Tracef("state machine [%s] no transition for event %s in state %s", sm.name, event, current)
+		return false
+	}
+
+	if t.Guard != nil && !t.Guard(args) {
+		log.Tracef("state machine [%s] transition %s -[%s]-> %s rejected by guard", sm.name, t.From, event, t.To)
+		return false
+	}
+
+	if t.Action != nil {
+		t.Action(args)
+	}
+
+	return sm.MoveToState(t.To)
+}
+
 // RegisterState registers a new state to the machine.
 // The old is replaced if a state with the same name exists.
 //
@@ -244,14 +425,19 @@ func (sm *StateMachine) Resume() {
 //
 //	NOTE: Not goroutine-safe.
 func (sm *StateMachine) SaveState() {
-	sm.saved = sm.GetState()
+	sm.saved = sm.path(sm.GetState())
 }
 
-// RestoreState moves to the latest saved state.
+// RestoreState moves to the latest saved state, restoring the full
+// active-state path rather than just the leaf.
 //
 //	NOTE: Not goroutine-safe.
 func (sm *StateMachine) RestoreState() {
-	sm.MoveToState(sm.saved)
+	if len(sm.saved) == 0 {
+		return
+	}
+
+	sm.MoveToState(sm.saved[len(sm.saved)-1])
 }
 
 // triggers execution of the action defined in current state.
@@ -0,0 +1,118 @@
+package service
+
+// Closer is implemented by pluggable transport adapters, such as the
+// HTTP bridge, that need to be stopped alongside the manager.
+type Closer interface {
+	Stop()
+}
+
+// Starter is implemented by transport adapters, such as the HTTP
+// bridge, whose Start must not run ahead of RegistryManager.Startup.
+// A Closer that also implements Starter has its Start deferred to
+// Startup instead of running at Attach time.
+type Starter interface {
+	Start()
+}
+
+// Attach registers c to be stopped when the manager shuts down and,
+// if c also implements Starter, to be started from Startup rather
+// than immediately, so it never comes up ahead of the registry server
+// itself. Intended for transport adapters built on top of the exported
+// QueryStatus/QueryStatusList/PublishStatus/Subscribe surface, such
+// as service/httpbridge.
+func (s *RegistryManager) Attach(c Closer) {
+	s.closers = append(s.closers, c)
+
+	if st, ok := c.(Starter); ok {
+		s.starters = append(s.starters, st)
+	}
+}
+
+// QueryStatus returns the status of the named service in namespace,
+// or nil if it isn't registered. It's the same code path handleQueryStatus
+// uses internally, exported for out-of-process transport adapters.
+func (s *RegistryManager) QueryStatus(namespace, name string) *Status {
+	namespace = namespaceOf(namespace, s.defaultNamespace)
+
+	reg := s.get(namespace, name)
+	if reg == nil {
+		return nil
+	}
+
+	return reg.toStatus()
+}
+
+// QueryStatusList returns the status of every service in namespace,
+// or across every namespace when allNamespaces is true, optionally
+// filtered down to names.
+func (s *RegistryManager) QueryStatusList(namespace string, names []string, allNamespaces bool) *StatusList {
+	namespace = namespaceOf(namespace, s.defaultNamespace)
+
+	var list StatusList
+	regs := s.all(namespace, allNamespaces)
+
+	if len(names) == 0 {
+		for _, reg := range regs {
+			list.Services = append(list.Services, reg.toStatus())
+		}
+		return &list
+	}
+
+	for _, name := range names {
+		for _, reg := range regs {
+			if reg.name == name {
+				list.Services = append(list.Services, reg.toStatus())
+			}
+		}
+	}
+
+	return &list
+}
+
+// PublishStatus feeds a status report into the same code path
+// handleStatus uses for heartbeats received over JSON-RPC/IPC,
+// letting transport adapters reuse it as-is.
+func (s *RegistryManager) PublishStatus(data []byte) {
+	s.handleStatus(data)
+}
+
+// Subscribe returns a channel fed with every status change observed
+// by the manager, and a cancel func to stop the feed. Unlike the
+// EndpointServiceNotice JSON-RPC notification, this is an in-process
+// fan-out meant for transport adapters such as the HTTP/WebSocket bridge.
+func (s *RegistryManager) Subscribe() (<-chan *Status, func()) {
+	ch := make(chan *Status, 16)
+
+	s.noticeMutex.Lock()
+	s.noticeSubs = append(s.noticeSubs, ch)
+	s.noticeMutex.Unlock()
+
+	cancel := func() {
+		s.noticeMutex.Lock()
+		defer s.noticeMutex.Unlock()
+
+		for i, cur := range s.noticeSubs {
+			if cur == ch {
+				s.noticeSubs = append(s.noticeSubs[:i], s.noticeSubs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}
+
+	return ch, cancel
+}
+
+// fanoutNotice pushes status to every active Subscribe channel,
+// dropping it for slow consumers instead of blocking the caller.
+func (s *RegistryManager) fanoutNotice(status *Status) {
+	s.noticeMutex.Lock()
+	defer s.noticeMutex.Unlock()
+
+	for _, ch := range s.noticeSubs {
+		select {
+		case ch <- status:
+		default:
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultCacheTTL is how long a cached *Status is served before
+// a fresh lookup against the RegistryManager is required.
+const defaultCacheTTL = time.Minute
+
+// maxStaleBackoff caps the exponential TTL extension applied while
+// the registry manager is unreachable.
+const maxStaleBackoff = 10 * time.Minute
+
+// cacheEntry holds a cached status together with its bookkeeping.
+type cacheEntry struct {
+	status    *Status
+	fetchTime time.Time
+	ttl       time.Duration //effective ttl, possibly backed off
+	lastErr   error
+	stale     bool //true when served past its original ttl due to errors
+}
+
+func (e *cacheEntry) expired() bool {
+	return time.Since(e.fetchTime) > e.ttl
+}
+
+// RegistryCache sits between service clients and a RegistryManager,
+// caching *Status by service name so high-QPS callers don't hammer
+// the registry over JSON-RPC. It invalidates entries reactively via
+// EndpointServiceNotice and falls back to the last known good value,
+// marked stale, while the manager is unreachable.
+type RegistryCache struct {
+	client *RegistryClient //existing JSON-RPC client used for QueryStatus/QueryStatusList
+
+	ttl time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*cacheEntry
+
+	inflight map[string]*sync.WaitGroup //singleflight de-duplication of concurrent misses
+
+	watchersM sync.Mutex
+	watchers  map[string][]chan *Status
+
+	stopOnce sync.Once
+}
+
+// CacheOption customizes a RegistryCache at construction time.
+type CacheOption func(*RegistryCache)
+
+// WithCacheTTL overrides the default one-minute TTL.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *RegistryCache) {
+		c.ttl = ttl
+	}
+}
+
+// NewRegistryCache creates a cache fronting the given client and
+// subscribes to EndpointServiceNotice for invalidation.
+func NewRegistryCache(client *RegistryClient, opts ...CacheOption) *RegistryCache {
+	c := &RegistryCache{
+		client:   client,
+		ttl:      defaultCacheTTL,
+		entries:  make(map[string]*cacheEntry),
+		inflight: make(map[string]*sync.WaitGroup),
+		watchers: make(map[string][]chan *Status),
+	}
+
+	for _, fn := range opts {
+		fn(c)
+	}
+
+	_ = client.Listen(EndpointServiceNotice, c.handleNotice)
+
+	log.Infoln("registry cache created")
+
+	return c
+}
+
+// Stop closes all channels handed out through Watch. Safe to call
+// concurrently and more than once.
+func (c *RegistryCache) Stop() {
+	c.stopOnce.Do(func() {
+		c.watchersM.Lock()
+		defer c.watchersM.Unlock()
+
+		for name, chans := range c.watchers {
+			for _, ch := range chans {
+				close(ch)
+			}
+			delete(c.watchers, name)
+		}
+
+		log.Infoln("registry cache stopped")
+	})
+}
+
+// Watch returns a channel fed with status updates for the named
+// service as they're observed, and a cancel func to stop the feed.
+func (c *RegistryCache) Watch(name string) (<-chan *Status, func()) {
+	ch := make(chan *Status, 1)
+
+	c.watchersM.Lock()
+	c.watchers[name] = append(c.watchers[name], ch)
+	c.watchersM.Unlock()
+
+	cancel := func() {
+		c.watchersM.Lock()
+		defer c.watchersM.Unlock()
+
+		chans := c.watchers[name]
+		for i, cur := range chans {
+			if cur == ch {
+				c.watchers[name] = append(chans[:i], chans[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}
+
+	return ch, cancel
+}
+
+// GetService returns the cached status for name, fetching and
+// caching it first if absent or expired.
+func (c *RegistryCache) GetService(name string) (*Status, bool) {
+	entry := c.getStatus(name)
+	if entry != nil && !entry.expired() {
+		return entry.status, entry.stale
+	}
+
+	return c.fetch(name)
+}
+
+// GetServiceList returns the cached status for every name given,
+// fetching misses individually.
+func (c *RegistryCache) GetServiceList(names []string) []*Status {
+	var list []*Status
+	for _, name := range names {
+		if status, _ := c.GetService(name); status != nil {
+			list = append(list, status)
+		}
+	}
+
+	return list
+}
+
+func (c *RegistryCache) getStatus(name string) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.entries[name]
+}
+
+func (c *RegistryCache) setStatus(name string, status *Status, err error) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := c.entries[name]
+	if entry == nil {
+		entry = &cacheEntry{}
+		c.entries[name] = entry
+	}
+
+	if err != nil {
+		entry.lastErr = err
+		entry.stale = entry.status != nil
+		entry.ttl = c.backoff(entry.ttl)
+		entry.fetchTime = time.Now()
+		return entry
+	}
+
+	entry.status = status
+	entry.lastErr = nil
+	entry.stale = false
+	entry.ttl = c.ttl
+	entry.fetchTime = time.Now()
+
+	return entry
+}
+
+// backoff extends the effective ttl exponentially, up to maxStaleBackoff,
+// while the registry manager stays unreachable.
+func (c *RegistryCache) backoff(cur time.Duration) time.Duration {
+	if cur == 0 {
+		cur = c.ttl
+	}
+
+	next := cur * 2
+	if next > maxStaleBackoff {
+		next = maxStaleBackoff
+	}
+
+	return next
+}
+
+// fetch performs a de-duplicated lookup against the registry manager,
+// so concurrent misses for the same name share a single RPC call.
+func (c *RegistryCache) fetch(name string) (*Status, bool) {
+	c.mutex.Lock()
+	if wg, ok := c.inflight[name]; ok {
+		c.mutex.Unlock()
+		wg.Wait()
+
+		entry := c.getStatus(name)
+		if entry == nil {
+			return nil, false
+		}
+		return entry.status, entry.stale
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	c.inflight[name] = wg
+	c.mutex.Unlock()
+
+	status, err := c.client.QueryStatus(name)
+
+	entry := c.setStatus(name, status, err)
+
+	c.mutex.Lock()
+	delete(c.inflight, name)
+	c.mutex.Unlock()
+
+	wg.Done()
+
+	if err != nil {
+		log.Warnf("registry cache: query status of %s failed, serving stale entry: %v", name, err)
+	}
+
+	if entry.status == nil {
+		return nil, false
+	}
+
+	return entry.status, entry.stale
+}
+
+// handleNotice invalidates the cache entry of a service whose state
+// changed, and fans the update out to any active watchers.
+func (c *RegistryCache) handleNotice(data []byte) {
+	status := &Status{}
+	if err := json.Unmarshal(data, status); err != nil {
+		log.Errorln("registry cache: unmarshal notice failed:", err)
+		return
+	}
+
+	c.mutex.Lock()
+	delete(c.entries, status.Name)
+	c.mutex.Unlock()
+
+	// held across the send so a concurrent Stop can't close these
+	// channels out from under us
+	c.watchersM.Lock()
+	defer c.watchersM.Unlock()
+
+	for _, ch := range c.watchers[status.Name] {
+		select {
+		case ch <- status:
+		default:
+			//slow consumer, drop rather than block the notice handler
+		}
+	}
+}
@@ -0,0 +1,484 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+	log "github.com/sirupsen/logrus"
+	"github.com/zourva/pareto/box"
+)
+
+// clusterOp identifies the kind of change carried by a gossiped clusterEvent.
+type clusterOp int
+
+const (
+	opCreate clusterOp = iota
+	opUpdate
+	opDelete
+)
+
+// clusterEvent is broadcast across the gossip mesh whenever a local
+// change happens to the registry repository, so every peer converges
+// on the same view without a central coordinator.
+type clusterEvent struct {
+	Op         clusterOp `json:"op"`
+	Status     *Status   `json:"status"`
+	UpdateTime uint64    `json:"updateTime"` //monotonic version used for last-writer-wins
+	Tombstone  bool      `json:"tombstone"`  //true once a Stopped de-registration has propagated
+}
+
+// Invalidates implements memberlist.Broadcast: a still-queued
+// broadcast for the same (namespace, name) is superseded by this
+// one, so the mesh doesn't waste bandwidth gossiping a stale version.
+func (e *clusterEvent) Invalidates(b memberlist.Broadcast) bool {
+	other, ok := b.(*clusterEvent)
+	if !ok || other.Status == nil || e.Status == nil {
+		return false
+	}
+
+	return other.Status.Namespace == e.Status.Namespace &&
+		other.Status.Name == e.Status.Name
+}
+
+func (e *clusterEvent) Message() []byte {
+	data, _ := json.Marshal(e)
+	return data
+}
+
+// Finished implements memberlist.Broadcast. No cleanup is needed
+// once a clusterEvent has finished propagating.
+func (e *clusterEvent) Finished() {}
+
+// cluster wires a RegistryManager into a memberlist gossip mesh so
+// any node can answer QueryStatus/QueryStatusList and any node's
+// timeout loop contributes to cluster-wide offline detection.
+type cluster struct {
+	mgr *RegistryManager
+
+	list  *memberlist.Memberlist
+	queue *memberlist.TransmitLimitedQueue
+
+	bindAddr      string
+	seeds         []string
+	connectRetry  int
+	connectPeriod time.Duration
+
+	tombstoneTTL time.Duration
+
+	tombstonesMu sync.Mutex
+	tombstones   map[svcKey]tombstone
+}
+
+// tombstone remembers a dead entry's version and expiry, so a
+// late-arriving update for the same service doesn't resurrect it
+// before the TTL has elapsed.
+type tombstone struct {
+	version uint64
+	expiry  time.Time
+}
+
+// Defaults of the settings the clustered-mode RegistryOptions override.
+var (
+	defaultConnectRetry  = 3
+	defaultConnectPeriod = 2 * time.Second
+	defaultTombstoneTTL  = 5 * time.Minute
+)
+
+// WithCluster enables gossip-based clustering, seeded with the
+// given peer addresses (host:port). Passing an empty list starts
+// a single-node mesh that others can join later.
+func WithCluster(seeds []string) RegistryOption {
+	return func(m *RegistryManager) {
+		if m.cluster == nil {
+			m.cluster = newCluster(m)
+		}
+		m.cluster.seeds = seeds
+	}
+}
+
+// WithBindAddr sets the host:port the gossip transport listens on.
+func WithBindAddr(addr string) RegistryOption {
+	return func(m *RegistryManager) {
+		if m.cluster == nil {
+			m.cluster = newCluster(m)
+		}
+		m.cluster.bindAddr = addr
+	}
+}
+
+// WithConnectRetry sets how many times joining the seed list is retried.
+func WithConnectRetry(n int) RegistryOption {
+	return func(m *RegistryManager) {
+		if m.cluster == nil {
+			m.cluster = newCluster(m)
+		}
+		m.cluster.connectRetry = n
+	}
+}
+
+// WithConnectTimeout sets the delay between join retries.
+func WithConnectTimeout(d time.Duration) RegistryOption {
+	return func(m *RegistryManager) {
+		if m.cluster == nil {
+			m.cluster = newCluster(m)
+		}
+		m.cluster.connectPeriod = d
+	}
+}
+
+func newCluster(mgr *RegistryManager) *cluster {
+	return &cluster{
+		mgr:           mgr,
+		connectRetry:  defaultConnectRetry,
+		connectPeriod: defaultConnectPeriod,
+		tombstoneTTL:  defaultTombstoneTTL,
+		tombstones:    make(map[svcKey]tombstone),
+	}
+}
+
+// tombstoned reports the recorded tombstone version for key if one
+// is on file and hasn't yet expired, pruning it otherwise.
+func (c *cluster) tombstoned(key svcKey) (uint64, bool) {
+	c.tombstonesMu.Lock()
+	defer c.tombstonesMu.Unlock()
+
+	t, ok := c.tombstones[key]
+	if !ok {
+		return 0, false
+	}
+
+	if time.Now().After(t.expiry) {
+		delete(c.tombstones, key)
+		return 0, false
+	}
+
+	return t.version, true
+}
+
+// recordTombstone remembers key as dead at version until the TTL
+// elapses, so out-of-order updates older than it don't resurrect it.
+func (c *cluster) recordTombstone(key svcKey, version uint64) {
+	c.tombstonesMu.Lock()
+	defer c.tombstonesMu.Unlock()
+
+	c.tombstones[key] = tombstone{
+		version: version,
+		expiry:  time.Now().Add(c.tombstoneTTL),
+	}
+}
+
+// pruneTombstones drops every expired tombstone. tombstoned() only
+// expires the one key it's asked about, so a service retired once and
+// never looked up again would otherwise linger in the map forever;
+// this is called once per RegistryManager.checkTimeout pass to bound
+// it regardless of lookup traffic.
+func (c *cluster) pruneTombstones() {
+	c.tombstonesMu.Lock()
+	defer c.tombstonesMu.Unlock()
+
+	now := time.Now()
+	for key, t := range c.tombstones {
+		if now.After(t.expiry) {
+			delete(c.tombstones, key)
+		}
+	}
+}
+
+// start joins or forms the gossip mesh. Called once during
+// RegistryManager.Startup when WithCluster was configured.
+func (c *cluster) start() error {
+	cfg := memberlist.DefaultLANConfig()
+	if c.bindAddr != "" {
+		host, port, err := splitHostPort(c.bindAddr)
+		if err == nil {
+			cfg.BindAddr = host
+			cfg.BindPort = port
+			cfg.AdvertisePort = port
+		}
+	}
+	cfg.Delegate = c
+	cfg.Events = c
+
+	list, err := memberlist.Create(cfg)
+	if err != nil {
+		return err
+	}
+	c.list = list
+
+	c.queue = &memberlist.TransmitLimitedQueue{
+		NumNodes:       func() int { return list.NumMembers() },
+		RetransmitMult: 3,
+	}
+
+	var joinErr error
+	for attempt := 0; attempt <= c.connectRetry; attempt++ {
+		if len(c.seeds) == 0 {
+			break
+		}
+
+		if _, joinErr = list.Join(c.seeds); joinErr == nil {
+			break
+		}
+
+		log.Warnf("cluster join attempt %d failed: %v", attempt+1, joinErr)
+		time.Sleep(c.connectPeriod)
+	}
+
+	log.Infof("registry cluster node %s up, %d member(s)", list.LocalNode().Name, list.NumMembers())
+
+	return nil
+}
+
+func (c *cluster) stop() {
+	if c.list == nil {
+		return
+	}
+
+	_ = c.list.Leave(c.connectPeriod)
+	_ = c.list.Shutdown()
+}
+
+// broadcast enqueues a clusterEvent for gossip propagation.
+func (c *cluster) broadcast(ev *clusterEvent) {
+	if c.queue == nil {
+		return
+	}
+
+	c.queue.QueueBroadcast(ev)
+}
+
+// sortedMembers snapshots the current membership, ordered by name so
+// every node shards the same way (memberlist.Members() order isn't
+// stable across nodes). Callers checking ownership of many services
+// in one pass should call this once and reuse it via ownsAmong,
+// rather than let owns() re-fetch and re-sort per service.
+func (c *cluster) sortedMembers() []*memberlist.Node {
+	if c.list == nil {
+		return nil
+	}
+
+	members := c.list.Members()
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+
+	return members
+}
+
+// owns reports whether the local node is responsible for running the
+// timeout check of namespace/name, so offline detection is sharded
+// across the cluster instead of duplicated on every node.
+func (c *cluster) owns(namespace, name string) bool {
+	if c.list == nil {
+		return true
+	}
+
+	return c.ownsAmong(namespace, name, c.sortedMembers())
+}
+
+// ownsAmong is owns() against a pre-sorted membership snapshot, so a
+// caller sharding many services in one pass can amortize the fetch
+// and sort across all of them.
+func (c *cluster) ownsAmong(namespace, name string, members []*memberlist.Node) bool {
+	if c.list == nil || len(members) == 0 {
+		return true
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(namespace))
+	_, _ = h.Write([]byte(name))
+	idx := int(h.Sum32()) % len(members)
+
+	return members[idx].Name == c.list.LocalNode().Name
+}
+
+// --- memberlist.Delegate ---
+
+func (c *cluster) NodeMeta(limit int) []byte { return nil }
+
+func (c *cluster) NotifyMsg(data []byte) {
+	var ev clusterEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		log.Errorln("cluster: invalid gossip message:", err)
+		return
+	}
+
+	if ev.Status == nil || ev.Status.Name == "" {
+		log.Errorln("cluster: malformed gossip message, missing status/name")
+		return
+	}
+
+	c.apply(&ev, true)
+}
+
+func (c *cluster) GetBroadcasts(overhead, limit int) [][]byte {
+	if c.queue == nil {
+		return nil
+	}
+	return c.queue.GetBroadcasts(overhead, limit)
+}
+
+// LocalState streams a gzip-compressed snapshot of the full registry
+// on peer join, used for anti-entropy sync.
+func (c *cluster) LocalState(join bool) []byte {
+	var list StatusList
+	for _, reg := range c.mgr.all("", true) {
+		list.Services = append(list.Services, reg.toStatus())
+	}
+
+	raw, _ := json.Marshal(&list)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = gw.Write(raw)
+	_ = gw.Close()
+
+	return buf.Bytes()
+}
+
+// MergeRemoteState applies a peer's gzipped snapshot, resolving
+// conflicts by last-writer-wins on UpdateTime.
+func (c *cluster) MergeRemoteState(buf []byte, join bool) {
+	gr, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		log.Errorln("cluster: invalid snapshot:", err)
+		return
+	}
+	defer gr.Close()
+
+	raw, err := io.ReadAll(gr)
+	if err != nil {
+		log.Errorln("cluster: failed reading snapshot:", err)
+		return
+	}
+
+	var list StatusList
+	if err := json.Unmarshal(raw, &list); err != nil {
+		log.Errorln("cluster: invalid snapshot payload:", err)
+		return
+	}
+
+	for _, status := range list.Services {
+		if status == nil || status.Name == "" {
+			continue
+		}
+		c.apply(&clusterEvent{Op: opUpdate, Status: status, UpdateTime: status.Time}, false)
+	}
+}
+
+// --- memberlist.EventDelegate ---
+
+func (c *cluster) NotifyJoin(node *memberlist.Node) {
+	log.Infof("cluster: node %s joined", node.Name)
+}
+
+func (c *cluster) NotifyLeave(node *memberlist.Node) {
+	log.Infof("cluster: node %s left", node.Name)
+}
+
+func (c *cluster) NotifyUpdate(node *memberlist.Node) {
+	log.Debugf("cluster: node %s updated", node.Name)
+}
+
+// apply merges a cluster event into the local repository, writing
+// directly into it rather than going through register/update so
+// applying a received event never re-broadcasts it or re-stamps its
+// version with the local clock. fromPeer distinguishes gossiped
+// events from anti-entropy sync, so notifyWatched only fires once
+// per logical change.
+func (c *cluster) apply(ev *clusterEvent, fromPeer bool) {
+	mgr := c.mgr
+
+	namespace := namespaceOf(ev.Status.Namespace, mgr.defaultNamespace)
+	key := svcKey{Namespace: namespace, Name: ev.Status.Name}
+	existing := mgr.get(namespace, ev.Status.Name)
+
+	// a tombstoned entry rejects any event not newer than the
+	// version it died at, so it can't be resurrected while its TTL
+	// hasn't elapsed
+	if version, tombstoned := c.tombstoned(key); tombstoned && ev.UpdateTime <= version {
+		return
+	}
+
+	// last-writer-wins: ignore events older than what we already have,
+	// a delayed tombstone included, so a stale DELETE can't destroy a
+	// registration that was legitimately re-created since
+	if existing != nil && existing.updateTime >= ev.UpdateTime {
+		return
+	}
+
+	if ev.Tombstone {
+		c.recordTombstone(key, ev.UpdateTime)
+
+		if existing != nil {
+			mgr.services.Delete(key)
+			if fromPeer {
+				mgr.notifyWatched(existing, ev.Status)
+			}
+		}
+		return
+	}
+
+	if existing == nil {
+		reg := mgr.registry(ev.Status)
+		reg.updateTime = ev.UpdateTime
+		mgr.services.Store(reg.key(), reg)
+		log.Infof("service %s registered in namespace %s (remote), state = %s",
+			reg.name, reg.namespace, reg.state.String())
+		return
+	}
+
+	if fromPeer && (existing.state != ev.Status.State || existing.ready != ev.Status.Ready) {
+		mgr.notifyWatched(existing, ev.Status)
+	}
+
+	existing.update(ev.Status)
+	existing.updateTime = ev.UpdateTime
+
+	if existing.state == Stopped {
+		c.recordTombstone(key, ev.UpdateTime)
+		mgr.services.Delete(key)
+	}
+}
+
+// tombstone broadcasts a de-registration as a dying entry with a TTL,
+// instead of a silent delete, so late-arriving updates for the same
+// service don't resurrect it once it's gone.
+func (c *cluster) tombstone(namespace, name string) {
+	version := box.TimeNowMs()
+
+	// record locally too: unregister() already removed the entry on
+	// this node, but without a tombstone a later anti-entropy pull
+	// or a delayed peer create for the same version could resurrect it
+	c.recordTombstone(svcKey{Namespace: namespace, Name: name}, version)
+
+	c.broadcast(&clusterEvent{
+		Op:         opDelete,
+		Status:     &Status{Namespace: namespace, Name: name, State: Stopped},
+		UpdateTime: version,
+		Tombstone:  true,
+	})
+}
+
+func splitHostPort(addr string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return host, port, nil
+}
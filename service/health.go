@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zourva/pareto/box"
+	"github.com/zourva/pareto/endec/jsonrpc2"
+)
+
+// Health is the method name of the active health-check RPC
+// exposed by every registered service, callable on EndpointServiceInfo.
+const Health = "Health"
+
+// QueryHealth is the method name used to retrieve the probe
+// results accumulated for a service.
+const QueryHealth = "QueryHealth"
+
+const (
+	// DefaultProbeInterval is the default period between two probe rounds.
+	DefaultProbeInterval = 10 * time.Second
+
+	// DefaultProbeConcurrency bounds how many probes run at the same time.
+	DefaultProbeConcurrency = 8
+
+	// DefaultFailureBudget is the number of consecutive probe failures
+	// allowed before a service is reaped regardless of heartbeat state.
+	DefaultFailureBudget = 3
+
+	// probeHistorySize is the number of most recent probe results kept per service.
+	probeHistorySize = 20
+)
+
+// HealthStatus is the outcome of a single health probe.
+type HealthStatus struct {
+	Success bool          `json:"success"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+	Time    uint64        `json:"time"` //timestamp in ms when probed
+}
+
+// HealthChecker abstracts a pluggable probe implementation, so
+// callers may add TCP/HTTP/custom checks alongside the default
+// JSON-RPC based one.
+type HealthChecker interface {
+	// Check probes the service described by reg and returns its
+	// health status or an error if the probe itself failed to run.
+	Check(ctx context.Context, reg *registry) (*HealthStatus, error)
+}
+
+// rpcHealthChecker is the default HealthChecker, issuing a
+// Health RPC call to EndpointServiceInfo on the probed service.
+type rpcHealthChecker struct {
+	timeout time.Duration
+}
+
+// NewRPCHealthChecker creates the default JSON-RPC health checker.
+func NewRPCHealthChecker(timeout time.Duration) HealthChecker {
+	return &rpcHealthChecker{timeout: timeout}
+}
+
+func (c *rpcHealthChecker) Check(ctx context.Context, reg *registry) (*HealthStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	req := jsonrpc2.NewRequest(Health, nil)
+	rsp, err := jsonrpc2.CallWithContext(ctx, reg.name, EndpointServiceInfo, req)
+	latency := time.Since(start)
+
+	if err != nil {
+		return &HealthStatus{
+			Success: false,
+			Latency: latency,
+			Error:   err.Error(),
+			Time:    box.TimeNowMs(),
+		}, nil
+	}
+
+	if rsp != nil && rsp.Error != nil {
+		return &HealthStatus{
+			Success: false,
+			Latency: latency,
+			Error:   rsp.Error.Message,
+			Time:    box.TimeNowMs(),
+		}, nil
+	}
+
+	return &HealthStatus{
+		Success: true,
+		Latency: latency,
+		Time:    box.TimeNowMs(),
+	}, nil
+}
+
+// healthRecord keeps the rolling probe history for a single service.
+type healthRecord struct {
+	mutex sync.Mutex
+
+	results     []*HealthStatus //ring buffer, most recent last
+	consecutive uint             //consecutive failures observed
+
+	lastError   string
+	lastLatency time.Duration
+	ready       bool //derived readiness, distinct from heartbeat-driven state
+}
+
+func newHealthRecord() *healthRecord {
+	return &healthRecord{ready: true}
+}
+
+// record appends a probe result and updates the rolling counters.
+// It returns true if the derived readiness flipped as a result.
+func (h *healthRecord) record(status *HealthStatus, budget uint) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.results = append(h.results, status)
+	if len(h.results) > probeHistorySize {
+		h.results = h.results[len(h.results)-probeHistorySize:]
+	}
+
+	h.lastLatency = status.Latency
+	h.lastError = status.Error
+
+	if status.Success {
+		h.consecutive = 0
+	} else {
+		h.consecutive++
+	}
+
+	before := h.ready
+	h.ready = h.consecutive < budget
+	return before != h.ready
+}
+
+// successRate returns the fraction of successful probes in the
+// retained history, or 1 when no probe has run yet.
+func (h *healthRecord) successRate() float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.successRateLocked()
+}
+
+// successRateLocked is successRate for callers already holding h.mutex.
+func (h *healthRecord) successRateLocked() float64 {
+	if len(h.results) == 0 {
+		return 1
+	}
+
+	var ok int
+	for _, r := range h.results {
+		if r.Success {
+			ok++
+		}
+	}
+
+	return float64(ok) / float64(len(h.results))
+}
+
+func (h *healthRecord) toStatus(name string) *QueryHealthRsp {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return &QueryHealthRsp{
+		Name:        name,
+		Ready:       h.ready,
+		Latency:     h.lastLatency,
+		LastError:   h.lastError,
+		SuccessRate: h.successRateLocked(),
+		Consecutive: h.consecutive,
+	}
+}
+
+// QueryHealthReq requests the accumulated probe result of a service.
+type QueryHealthReq struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// QueryHealthRsp reports the accumulated probe result of a service.
+type QueryHealthRsp struct {
+	Name        string        `json:"name"`
+	Ready       bool          `json:"ready"`
+	Latency     time.Duration `json:"latency"`
+	LastError   string        `json:"lastError,omitempty"`
+	SuccessRate float64       `json:"successRate"`
+	Consecutive uint          `json:"consecutive"`
+}
+
+// startHealthProbe starts the periodic probe loop, if a checker was
+// configured. QueryHealth itself is registered by Startup alongside
+// QueryStatus/QueryStatusList, in a single AddChannel call, so this
+// has nothing left to wire when there's no active checker.
+func (s *RegistryManager) startHealthProbe() {
+	if s.healthChecker == nil {
+		return
+	}
+
+	s.probeTimer = time.AfterFunc(s.probeInterval, s.probeAll)
+
+	log.Infoln("registry manager active health probe started")
+}
+
+// ServeHealth wires the Health responder on ms's EndpointServiceInfo
+// channel, answering every call with success. Services that register
+// with a RegistryManager configured via WithHealthChecker(NewRPCHealthChecker(...))
+// must call this (typically right after NewMetaService) so the active
+// probe has something to call instead of failing with method-not-found.
+func ServeHealth(ms *MetaService) {
+	ms.RpcServer().Router().AddChannel(
+		EndpointServiceInfo,
+		map[string]jsonrpc2.Handler{
+			Health: handleHealth,
+		})
+}
+
+func handleHealth(req *jsonrpc2.RPCRequest) *jsonrpc2.RPCResponse {
+	return jsonrpc2.NewResponse(req, struct{}{})
+}
+
+func (s *RegistryManager) stopHealthProbe() {
+	if s.probeTimer != nil {
+		s.probeTimer.Stop()
+	}
+}
+
+// probeAll runs one round of health checks across all registered
+// services, bounded to probeConcurrency in-flight probes so a large
+// registry with a slow checker cannot starve the timeout loop.
+func (s *RegistryManager) probeAll() {
+	sem := make(chan struct{}, s.probeConcurrency)
+	var wg sync.WaitGroup
+
+	for _, reg := range s.all("", true) {
+		reg := reg
+
+		// don't probe services waiting for revival after going offline
+		if reg.state == Offline {
+			continue
+		}
+
+		// a service with a fresh heartbeat within one probe interval
+		// is not probed this round, to avoid flapping
+		if box.TimeNowMs()-reg.updateTime < uint64(s.probeInterval.Milliseconds()) {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.probeOne(reg)
+		}()
+	}
+
+	wg.Wait()
+
+	s.probeTimer.Reset(s.probeInterval)
+}
+
+func (s *RegistryManager) probeOne(reg *registry) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.probeInterval)
+	defer cancel()
+
+	status, err := s.healthChecker.Check(ctx, reg)
+	if err != nil {
+		log.Warnf("health probe for service %s failed to run: %v", reg.name, err)
+		return
+	}
+
+	rec, _ := s.health.LoadOrStore(reg.key(), newHealthRecord())
+	record := rec.(*healthRecord)
+
+	if flipped := record.record(status, s.failureBudget); flipped {
+		s.notifyHealthChange(reg, record)
+	}
+
+	if record.consecutiveFailures() >= s.failureBudget {
+		s.unregister(reg.namespace, reg.name)
+		s.health.Delete(reg.key())
+		log.Infof("service %s reaped after %d consecutive probe failures", reg.name, s.failureBudget)
+	}
+}
+
+func (h *healthRecord) consecutiveFailures() uint {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.consecutive
+}
+
+// notifyHealthChange emits a state-change notification distinct
+// from heartbeat-driven Offline transitions.
+func (s *RegistryManager) notifyHealthChange(reg *registry, rec *healthRecord) {
+	log.Infof("service %s derived readiness changed(ready=%t)", reg.name, rec.ready)
+
+	status := reg.toStatus()
+	status.Ready = rec.ready
+
+	data, _ := json.Marshal(status)
+	_ = s.Notify(EndpointServiceNotice, data)
+	s.fanoutNotice(status)
+}
+
+func (s *RegistryManager) handleQueryHealth(req *jsonrpc2.RPCRequest) *jsonrpc2.RPCResponse {
+	var reqObj QueryHealthReq
+	if err := req.GetObject(&reqObj); err != nil {
+		return jsonrpc2.NewErrorResponseWithCodeOnly(jsonrpc2.ErrServerInvalidParameters)
+	}
+
+	namespace := namespaceOf(reqObj.Namespace, namespaceFromRequest(req, s.defaultNamespace))
+
+	rec, ok := s.health.Load(svcKey{Namespace: namespace, Name: reqObj.Name})
+	if !ok {
+		return jsonrpc2.NewErrorResponse(jsonrpc2.ErrServerInvalid, "no health record for service")
+	}
+
+	return jsonrpc2.NewResponse(req, rec.(*healthRecord).toStatus(reqObj.Name))
+}
+
+// WithHealthChecker installs a HealthChecker and enables the
+// active probe subsystem alongside passive heartbeat handling.
+func WithHealthChecker(checker HealthChecker) RegistryOption {
+	return func(m *RegistryManager) {
+		m.healthChecker = checker
+	}
+}
+
+// WithProbeInterval sets the period between two probe rounds.
+func WithProbeInterval(d time.Duration) RegistryOption {
+	return func(m *RegistryManager) {
+		m.probeInterval = d
+	}
+}
+
+// WithProbeConcurrency bounds the number of probes in flight at once.
+func WithProbeConcurrency(n int) RegistryOption {
+	return func(m *RegistryManager) {
+		m.probeConcurrency = n
+	}
+}
+
+// WithFailureBudget sets the number of consecutive probe failures
+// allowed before a service is reaped, independent of heartbeat state.
+func WithFailureBudget(n uint) RegistryOption {
+	return func(m *RegistryManager) {
+		m.failureBudget = n
+	}
+}
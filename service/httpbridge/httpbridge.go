@@ -0,0 +1,278 @@
+// Package httpbridge exposes a RegistryManager over HTTP/JSON and
+// WebSocket in addition to its native JSON-RPC-over-IPC transport,
+// so dashboards, CLIs, and out-of-process monitoring can integrate
+// with the registry without linking pareto.
+package httpbridge
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"github.com/zourva/pareto/service"
+)
+
+const (
+	pingPeriod = 30 * time.Second
+	pongWait   = 60 * time.Second
+)
+
+// Authenticator gates access to the bridge's endpoints. Returning
+// false fails the request with http.StatusUnauthorized.
+type Authenticator interface {
+	Authenticate(r *http.Request) bool
+}
+
+// bearerAuthenticator is the bundled Authenticator checking for a
+// fixed bearer token in the Authorization header.
+type bearerAuthenticator struct {
+	token string
+}
+
+// NewBearerAuthenticator creates an Authenticator requiring the
+// "Authorization: Bearer <token>" header to match token.
+func NewBearerAuthenticator(token string) Authenticator {
+	return &bearerAuthenticator{token: token}
+}
+
+func (a *bearerAuthenticator) Authenticate(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	return header == "Bearer "+a.token
+}
+
+// Bridge is an HTTP/JSON + WebSocket transport adapter in front of a
+// service.RegistryManager. It reuses the manager's exported
+// QueryStatus/QueryStatusList/PublishStatus/Subscribe surface as-is;
+// the bridge itself only translates HTTP/WebSocket to that surface.
+type Bridge struct {
+	mgr  *service.RegistryManager
+	addr string
+	auth Authenticator
+
+	upgrader websocket.Upgrader
+	server   *http.Server
+
+	mutex  sync.Mutex
+	closed bool
+	conns  map[*websocket.Conn]struct{} //live /v1/watch connections, closed by Stop
+}
+
+// BridgeOption customizes a Bridge at construction time.
+type BridgeOption func(*Bridge)
+
+// WithAuthenticator installs an Authenticator guarding every endpoint.
+// With none set, the bridge is open.
+func WithAuthenticator(a Authenticator) BridgeOption {
+	return func(b *Bridge) {
+		b.auth = a
+	}
+}
+
+// NewBridge creates a Bridge serving mgr over addr.
+func NewBridge(mgr *service.RegistryManager, addr string, opts ...BridgeOption) *Bridge {
+	b := &Bridge{
+		mgr:   mgr,
+		addr:  addr,
+		conns: make(map[*websocket.Conn]struct{}),
+	}
+
+	for _, fn := range opts {
+		fn(b)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/services", b.withAuth(b.handleServiceList))
+	mux.HandleFunc("/v1/services/", b.withAuth(b.handleService))
+	mux.HandleFunc("/v1/watch", b.withAuth(b.handleWatch))
+
+	b.server = &http.Server{Addr: addr, Handler: mux}
+
+	return b
+}
+
+// Start runs the HTTP server in a background goroutine.
+func (b *Bridge) Start() {
+	go func() {
+		if err := b.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorln("httpbridge: server exited:", err)
+		}
+	}()
+
+	log.Infof("httpbridge: serving registry on %s", b.addr)
+}
+
+// Stop shuts the HTTP server down and closes every live /v1/watch
+// connection, so each handleWatch loop returns and releases its
+// mgr.Subscribe() feed instead of leaking past Stop. Safe to call
+// concurrently and more than once.
+func (b *Bridge) Stop() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	_ = b.server.Close()
+
+	for conn := range b.conns {
+		_ = conn.Close()
+	}
+	b.conns = nil
+
+	log.Infoln("httpbridge: stopped")
+}
+
+// WithHTTPBridge creates a Bridge fronting the manager on addr and
+// attaches it. Bridge implements both service.Closer and
+// service.Starter, so Attach defers its Start to
+// RegistryManager.Startup and runs its Stop alongside
+// RegistryManager.Shutdown.
+func WithHTTPBridge(addr string, opts ...BridgeOption) service.RegistryOption {
+	return func(m *service.RegistryManager) {
+		b := NewBridge(m, addr, opts...)
+		m.Attach(b)
+	}
+}
+
+func (b *Bridge) withAuth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if b.auth != nil && !b.auth.Authenticate(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// handleServiceList serves GET /v1/services?names=a,b&namespace=x,
+// mapping onto RegistryManager.QueryStatusList.
+func (b *Bridge) handleServiceList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+
+	var names []string
+	if raw := q.Get("names"); raw != "" {
+		names = strings.Split(raw, ",")
+	}
+
+	list := b.mgr.QueryStatusList(q.Get("namespace"), names, q.Get("allNamespaces") == "true")
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleService serves GET and POST on /v1/services/{name}, mapping
+// onto QueryStatus and PublishStatus respectively.
+func (b *Bridge) handleService(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/v1/services/")
+	name, sub, _ := strings.Cut(rest, "/")
+	if name == "" {
+		http.Error(w, "service name required", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && sub == "":
+		status := b.mgr.QueryStatus(r.URL.Query().Get("namespace"), name)
+		if status == nil {
+			http.Error(w, "service not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+
+	case r.Method == http.MethodPost && sub == "status":
+		var status service.Status
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			http.Error(w, "invalid status body", http.StatusBadRequest)
+			return
+		}
+		status.Name = name
+
+		data, _ := json.Marshal(&status)
+		b.mgr.PublishStatus(data)
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+// handleWatch upgrades GET /v1/watch to a WebSocket connection and
+// streams EndpointServiceNotice-equivalent updates as JSON frames,
+// with a server-driven ping/pong keepalive.
+func (b *Bridge) handleWatch(w http.ResponseWriter, r *http.Request) {
+	conn, err := b.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warnln("httpbridge: websocket upgrade failed:", err)
+		return
+	}
+	defer conn.Close()
+
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return
+	}
+	b.conns[conn] = struct{}{}
+	b.mutex.Unlock()
+
+	defer func() {
+		b.mutex.Lock()
+		delete(b.conns, conn)
+		b.mutex.Unlock()
+	}()
+
+	updates, cancel := b.mgr.Subscribe()
+	defer cancel()
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	// drain and discard client frames so pong control messages are processed
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case status, ok := <-updates:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(status); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
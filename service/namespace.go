@@ -0,0 +1,62 @@
+package service
+
+import (
+	"github.com/zourva/pareto/endec/jsonrpc2"
+)
+
+// DefaultNamespace is used for services and queries that don't
+// specify one explicitly.
+const DefaultNamespace = "default"
+
+// NamespaceMetadataKey is the JSON-RPC request metadata key carrying
+// the caller's namespace, analogous to a namespace header, used when
+// the caller doesn't set Namespace explicitly in the request body.
+const NamespaceMetadataKey = "Pareto-Namespace"
+
+// svcKey identifies a registered service within a namespace, used as
+// the key of RegistryManager.services so a single registry can host
+// isolated tenants without name collisions.
+type svcKey struct {
+	Namespace string
+	Name      string
+}
+
+// namespaceOf defaults an empty namespace to fallback, so callers
+// that don't care about multi-tenancy keep working unchanged.
+func namespaceOf(namespace, fallback string) string {
+	if namespace == "" {
+		return fallback
+	}
+	return namespace
+}
+
+// namespaceFromRequest resolves the caller's namespace from the
+// request's metadata, falling back when it's absent.
+func namespaceFromRequest(req *jsonrpc2.RPCRequest, fallback string) string {
+	if req != nil && req.Meta != nil {
+		if ns, ok := req.Meta[NamespaceMetadataKey]; ok && ns != "" {
+			return ns
+		}
+	}
+
+	return fallback
+}
+
+// NamespacedDescriptor returns a copy of d with Namespace set, for
+// services that want to declare their namespace as part of their
+// identity at construction time, rather than relying on it arriving
+// later via a heartbeat's Status.Namespace or a request's
+// NamespaceMetadataKey metadata.
+func NamespacedDescriptor(d Descriptor, namespace string) *Descriptor {
+	d.Namespace = namespace
+	return &d
+}
+
+// WithDefaultNamespace overrides the namespace assumed for services
+// and queries that don't specify one explicitly. DefaultNamespace is
+// used otherwise.
+func WithDefaultNamespace(namespace string) RegistryOption {
+	return func(m *RegistryManager) {
+		m.defaultNamespace = namespace
+	}
+}
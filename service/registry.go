@@ -2,6 +2,7 @@ package service
 
 import (
 	"encoding/json"
+	"github.com/hashicorp/memberlist"
 	log "github.com/sirupsen/logrus"
 	"github.com/zourva/pareto/box"
 	"github.com/zourva/pareto/endec/jsonrpc2"
@@ -40,6 +41,8 @@ import (
 
 // service registry info
 type registry struct {
+	//tenant the service belongs to, "default" unless configured otherwise
+	namespace string
 	//service name
 	name   string
 	domain int
@@ -93,14 +96,20 @@ func (r *registry) update(s *Status) {
 
 func (r *registry) toStatus() *Status {
 	return &Status{
-		Name:   r.name,
-		Domain: r.domain,
-		State:  r.state,
-		Ready:  r.ready,
-		Time:   r.updateTime,
+		Namespace: r.namespace,
+		Name:      r.name,
+		Domain:    r.domain,
+		State:     r.state,
+		Ready:     r.ready,
+		Time:      r.updateTime,
 	}
 }
 
+// key returns the sync.Map key this registry entry is stored under.
+func (r *registry) key() svcKey {
+	return svcKey{Namespace: r.namespace, Name: r.name}
+}
+
 // RegistryManager manages all services as service clients.
 type RegistryManager struct {
 	*MetaService
@@ -108,6 +117,23 @@ type RegistryManager struct {
 	timer    *time.Timer   //timeout check timer
 	duration time.Duration //timeout check timer duration, 5s by default
 
+	health           sync.Map      //active probe results, keyed by svcKey
+	healthChecker    HealthChecker //pluggable active health checker, nil disables probing
+	probeTimer       *time.Timer   //active probe round timer
+	probeInterval    time.Duration //period between two probe rounds
+	probeConcurrency int           //bound on in-flight probes per round
+	failureBudget    uint          //consecutive probe failures allowed before reaping
+
+	cluster *cluster //gossip clustering, nil when running standalone
+
+	defaultNamespace string //namespace assumed when one isn't specified
+
+	noticeMutex sync.Mutex
+	noticeSubs  []chan *Status //active Subscribe feeds, for transport adapters
+
+	closers  []Closer  //transport adapters to stop alongside the manager
+	starters []Starter //transport adapters to start from Startup, in Attach order
+
 	//watchers map[string][]*Watcher
 	//mutex    sync.RWMutex
 }
@@ -119,6 +145,7 @@ func (s *RegistryManager) Startup() bool {
 		map[string]jsonrpc2.Handler{
 			QueryStatus:     s.handleQueryStatus,
 			QueryStatusList: s.handleQueryStatusList,
+			QueryHealth:     s.handleQueryHealth,
 		})
 
 	err := s.RpcServer().Serve()
@@ -134,6 +161,19 @@ func (s *RegistryManager) Startup() bool {
 
 	s.timer = time.AfterFunc(s.duration, s.checkTimeout)
 
+	s.startHealthProbe()
+
+	if s.cluster != nil {
+		if err := s.cluster.start(); err != nil {
+			log.Errorln("registry manager cluster startup failed:", err)
+			return false
+		}
+	}
+
+	for _, st := range s.starters {
+		st.Start()
+	}
+
 	log.Infoln("registry manager started")
 
 	return true
@@ -157,23 +197,34 @@ func (s *RegistryManager) Shutdown() {
 	//	}
 	//}
 	s.timer.Stop()
+	s.stopHealthProbe()
+
+	if s.cluster != nil {
+		s.cluster.stop()
+	}
+
+	for _, c := range s.closers {
+		c.Stop()
+	}
 
 	log.Infoln("registry manager shutdown")
 }
 
-// Registered returns true if the service is
-// registered to the center and false otherwise.
+// Registered returns true if the service is registered to the
+// center under the given namespace, and false otherwise.
 //
 //	This method is goroutine-safe.
-func (s *RegistryManager) Registered(name string) bool {
-	if _, ok := s.services.Load(name); ok {
+func (s *RegistryManager) Registered(namespace, name string) bool {
+	namespace = namespaceOf(namespace, s.defaultNamespace)
+
+	if _, ok := s.services.Load(svcKey{Namespace: namespace, Name: name}); ok {
 		return true
 	}
 
 	return false
 }
 
-// Count returns number of services registered.
+// Count returns number of services registered across all namespaces.
 func (s *RegistryManager) Count() int {
 	var counter = 0
 	s.services.Range(func(key, value any) bool {
@@ -185,22 +236,27 @@ func (s *RegistryManager) Count() int {
 }
 
 // GetService returns the service associated with the
-// given name or nil if not found.
+// given namespace and name, or nil if not found.
 //
 //	This method is goroutine-safe.
-func (s *RegistryManager) get(name string) *registry {
-	if sd, ok := s.services.Load(name); ok {
+func (s *RegistryManager) get(namespace, name string) *registry {
+	if sd, ok := s.services.Load(svcKey{Namespace: namespace, Name: name}); ok {
 		return sd.(*registry)
 	}
 
 	return nil
 }
 
-func (s *RegistryManager) all() []*registry {
+// all returns every registered entry in namespace, or across every
+// namespace when allNamespaces is true. Cross-namespace lookups
+// require this explicit flag so accidental fan-out is impossible.
+func (s *RegistryManager) all(namespace string, allNamespaces bool) []*registry {
 	var list []*registry
 	s.services.Range(func(key, value any) bool {
 		reg := value.(*registry)
-		list = append(list, reg)
+		if allNamespaces || reg.namespace == namespace {
+			list = append(list, reg)
+		}
 		return true
 	})
 
@@ -212,6 +268,7 @@ func (s *RegistryManager) registry(status *Status) *registry {
 	t := box.TimeNowMs()
 
 	r := &registry{
+		namespace:  namespaceOf(status.Namespace, s.defaultNamespace),
 		name:       status.Name,
 		domain:     status.Domain,
 		state:      status.State,
@@ -234,9 +291,14 @@ func (s *RegistryManager) registry(status *Status) *registry {
 //
 //	This method is goroutine-safe.
 func (s *RegistryManager) register(status *Status) {
-	s.services.Store(status.Name, s.registry(status))
+	reg := s.registry(status)
+	s.services.Store(reg.key(), reg)
+
+	log.Infof("service %s registered in namespace %s, state = %s", status.Name, reg.namespace, status.State.String())
 
-	log.Infof("service %s registered, state = %s", status.Name, status.State.String())
+	if s.cluster != nil {
+		s.cluster.broadcast(&clusterEvent{Op: opCreate, Status: status, UpdateTime: box.TimeNowMs()})
+	}
 }
 
 func (s *RegistryManager) update(reg *registry, status *Status) {
@@ -249,9 +311,13 @@ func (s *RegistryManager) update(reg *registry, status *Status) {
 	// overwrite states
 	reg.update(status)
 
+	if s.cluster != nil {
+		s.cluster.broadcast(&clusterEvent{Op: opUpdate, Status: status, UpdateTime: reg.updateTime})
+	}
+
 	// de-register if stopped normally
 	if reg.state == Stopped {
-		s.unregister(reg.name)
+		s.unregister(reg.namespace, reg.name)
 	}
 }
 
@@ -259,9 +325,13 @@ func (s *RegistryManager) update(reg *registry, status *Status) {
 // Does nothing when the service is not found.
 //
 //	This method is goroutine-safe.
-func (s *RegistryManager) unregister(name string) {
-	s.services.Delete(name)
-	log.Infof("service %s unregistered", name)
+func (s *RegistryManager) unregister(namespace, name string) {
+	s.services.Delete(svcKey{Namespace: namespace, Name: name})
+	log.Infof("service %s unregistered from namespace %s", name, namespace)
+
+	if s.cluster != nil {
+		s.cluster.tombstone(namespace, name)
+	}
 }
 
 func (s *RegistryManager) notifyWatched(reg *registry, status *Status) {
@@ -271,6 +341,8 @@ func (s *RegistryManager) notifyWatched(reg *registry, status *Status) {
 	data, _ := json.Marshal(status)
 	_ = s.Notify(EndpointServiceNotice, data)
 
+	s.fanoutNotice(status)
+
 	//s.mutex.RLock()
 	//defer s.mutex.RUnlock()
 	//
@@ -298,7 +370,10 @@ func (s *RegistryManager) handleStatus(data []byte) {
 		return
 	}
 
-	if ss, ok := s.services.Load(status.Name); ok {
+	status.Namespace = namespaceOf(status.Namespace, s.defaultNamespace)
+
+	key := svcKey{Namespace: status.Namespace, Name: status.Name}
+	if ss, ok := s.services.Load(key); ok {
 		reg := ss.(*registry)
 		s.update(reg, status)
 	} else {
@@ -351,17 +426,20 @@ func (s *RegistryManager) handleQueryStatus(req *jsonrpc2.RPCRequest) *jsonrpc2.
 		return jsonrpc2.NewErrorResponseWithCodeOnly(jsonrpc2.ErrServerInvalidParameters)
 	}
 
-	reg := s.get(reqObj.Name)
+	namespace := namespaceOf(reqObj.Namespace, namespaceFromRequest(req, s.defaultNamespace))
+
+	reg := s.get(namespace, reqObj.Name)
 	if reg == nil {
 		return jsonrpc2.NewErrorResponse(jsonrpc2.ErrServerInvalid, "service name does not exist")
 	}
 
 	return jsonrpc2.NewResponse(req, &QueryStatusRsp{Status: &Status{
-		Name:   reg.name,
-		Domain: reg.domain,
-		State:  reg.state,
-		Time:   reg.updateTime,
-		Ready:  reg.ready,
+		Namespace: reg.namespace,
+		Name:      reg.name,
+		Domain:    reg.domain,
+		State:     reg.state,
+		Time:      reg.updateTime,
+		Ready:     reg.ready,
 		//Metrics: reg.metrics,
 		//CheckInterval: uint32(reg.interval),
 		//AllowFailures: uint32(reg.threshold),
@@ -375,34 +453,26 @@ func (s *RegistryManager) handleQueryStatusList(req *jsonrpc2.RPCRequest) *jsonr
 		return jsonrpc2.NewErrorResponseWithCodeOnly(jsonrpc2.ErrServerInvalidParameters)
 	}
 
+	// cross-namespace lookups require the explicit AllNamespaces flag,
+	// so accidental fan-out across tenants is impossible
+	namespace := namespaceOf(reqObj.Namespace, namespaceFromRequest(req, s.defaultNamespace))
+
 	var list StatusList
-	all := s.all()
+	all := s.all(namespace, reqObj.AllNamespaces)
 	whitelist := reqObj.Observed
 	if whitelist != nil && len(whitelist) != 0 {
 		// if given whitelist, return them
 		for _, name := range whitelist {
 			for _, reg := range all {
 				if reg.name == name {
-					list.Services = append(list.Services, &Status{
-						Name:   reg.name,
-						Domain: reg.domain,
-						State:  reg.state,
-						Time:   reg.updateTime,
-						Ready:  reg.ready,
-					})
+					list.Services = append(list.Services, reg.toStatus())
 				}
 			}
 		}
 	} else {
 		// if no whitelist, return all
 		for _, reg := range all {
-			list.Services = append(list.Services, &Status{
-				Name:   reg.name,
-				Domain: reg.domain,
-				State:  reg.state,
-				Time:   reg.updateTime,
-				Ready:  reg.ready,
-			})
+			list.Services = append(list.Services, reg.toStatus())
 		}
 	}
 
@@ -412,12 +482,28 @@ func (s *RegistryManager) handleQueryStatusList(req *jsonrpc2.RPCRequest) *jsonr
 // checkTimeout iterates over each service
 // and checks if its state is deprecated.
 func (s *RegistryManager) checkTimeout() {
+	// snapshot membership once per pass rather than per service
+	var members []*memberlist.Node
+	if s.cluster != nil {
+		members = s.cluster.sortedMembers()
+		s.cluster.pruneTombstones()
+	}
+
 	s.services.Range(func(key, value any) bool {
 		service := value.(*registry)
+
+		// in clustered mode, only the node owning this service
+		// runs its timeout check, so offline detection isn't
+		// performed redundantly by every node
+		if s.cluster != nil && !s.cluster.ownsAmong(service.namespace, service.name, members) {
+			return true
+		}
+
 		if service.state == Offline {
 			if service.dead() {
-				//remove dead entries
-				s.services.Delete(key)
+				//remove dead entries, tombstoning and propagating
+				//the removal so peers don't resurrect it
+				s.unregister(service.namespace, service.name)
 			} else {
 				//wait for revival or dead
 			}
@@ -427,8 +513,16 @@ func (s *RegistryManager) checkTimeout() {
 				old := *service
 				//force offline to change state
 				service.offline()
+				status := service.toStatus()
 				//notify based on both old and new status
-				s.notifyWatched(&old, service.toStatus())
+				s.notifyWatched(&old, status)
+
+				// the timeout check is sharded to the owning node only,
+				// so the offline transition must be broadcast explicitly
+				// instead of waiting on anti-entropy to converge it
+				if s.cluster != nil {
+					s.cluster.broadcast(&clusterEvent{Op: opUpdate, Status: status, UpdateTime: service.updateTime})
+				}
 			}
 		}
 
@@ -450,8 +544,9 @@ func WithTimeoutCheckDuration(d time.Duration) RegistryOption {
 // and nil is returned if the meta service creation failed.
 func NewRegistryManager(registry string, opts ...RegistryOption) *RegistryManager {
 	regMgr := NewMetaService(&Descriptor{
-		Name:     Registry,
-		Registry: registry,
+		Name:      Registry,
+		Registry:  registry,
+		Namespace: DefaultNamespace,
 	})
 	if regMgr == nil {
 		log.Errorln("create registry manager failed")
@@ -459,8 +554,12 @@ func NewRegistryManager(registry string, opts ...RegistryOption) *RegistryManage
 	}
 
 	s := &RegistryManager{
-		MetaService: regMgr,
-		duration:    StatusCheckInterval * time.Second, // default
+		MetaService:      regMgr,
+		duration:         StatusCheckInterval * time.Second, // default
+		probeInterval:    DefaultProbeInterval,
+		probeConcurrency: DefaultProbeConcurrency,
+		failureBudget:    DefaultFailureBudget,
+		defaultNamespace: DefaultNamespace,
 		//watchers:    make(map[string][]*Watcher),
 	}
 